@@ -1,6 +1,7 @@
 package btf
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"strings"
@@ -9,12 +10,40 @@ import (
 var errNestedTooDeep = errors.New("nested too deep")
 
 type GoFormatter struct {
-	w     strings.Builder
-	names map[Type]string
+	w       strings.Builder
+	methods strings.Builder
+	names   map[Type]string
 
 	// Identifier is called for each field of struct-like types. By default the
 	// field name is used as is.
 	Identifier func(string) string
+
+	// EmitEnumMethods controls whether a String, MarshalJSON, UnmarshalJSON
+	// and IsValid method is generated for each *Enum passed to
+	// TypeDeclaration. These make the generated type usable directly in
+	// structured logs and JSON APIs, round-tripping through the symbolic
+	// name rather than the underlying integer.
+	EmitEnumMethods bool
+
+	// EmitFullUnions controls how *Union is rendered. By default, only the
+	// first member is kept, for backwards compatibility. When true, the
+	// union is represented as a byte array sized to v.Size, together with an
+	// AsFoo accessor method per member that reinterprets the bytes as that
+	// member's type via unsafe.Pointer, so no variant or trailing padding is
+	// silently discarded.
+	EmitFullUnions bool
+
+	// EmitBinaryCodec controls whether MarshalBinary and UnmarshalBinary
+	// methods, implementing encoding.BinaryMarshaler/BinaryUnmarshaler, are
+	// generated for each *Struct passed to TypeDeclaration. The methods
+	// encode straight-line reads/writes of every field via encoding/binary,
+	// using ByteOrder, without resorting to reflection. ByteOrder must be
+	// set when this is enabled.
+	EmitBinaryCodec bool
+
+	// ByteOrder is the byte order of the target the types were loaded for,
+	// typically Spec.ByteOrder. Only consulted when EmitBinaryCodec is true.
+	ByteOrder binary.ByteOrder
 }
 
 // NewGoFormatter creates a new GoFormatter.
@@ -29,12 +58,23 @@ func NewGoFormatter(names map[Type]string) *GoFormatter {
 }
 
 // TypeDeclaration generates a Go type declaration for a BTF type.
+//
+// The returned string may contain more than one Go declaration, separated by
+// newlines: some types (for example structs with bitfields, or enums when
+// EmitEnumMethods is set) require accompanying methods to be usable, which
+// are appended after the type declaration itself. Generated code may
+// reference "encoding/json", "encoding/binary", "fmt" and "unsafe", which
+// callers must import.
 func (gf *GoFormatter) TypeDeclaration(name string, typ Type) (string, error) {
 	gf.w.Reset()
+	gf.methods.Reset()
 	if err := gf.writeTypeDecl(name, typ); err != nil {
 		return "", err
 	}
-	return gf.w.String(), nil
+	if gf.methods.Len() == 0 {
+		return gf.w.String(), nil
+	}
+	return gf.w.String() + "\n\n" + gf.methods.String(), nil
 }
 
 // writeTypeDecl outputs a declaration of the given type.
@@ -67,11 +107,78 @@ func (gf *GoFormatter) writeTypeDecl(name string, typ Type) error {
 		}
 		gf.w.WriteString(")")
 
+		if gf.EmitEnumMethods {
+			gf.writeEnumMethods(name, v)
+		}
+
 		return nil
 	}
 
 	fmt.Fprintf(&gf.w, "type %s ", name)
-	return gf.writeTypeLit(typ, 0)
+	return gf.writeTypeLit(typ, 0, name)
+}
+
+// writeEnumMethods emits String, IsValid, MarshalJSON and UnmarshalJSON
+// methods for an enum named name, following the pattern encoding/json uses
+// for textual enumerations: JSON and log output round-trip through the
+// symbolic constant name instead of the bare integer.
+func (gf *GoFormatter) writeEnumMethods(name string, e *Enum) {
+	ids := make([]string, len(e.Values))
+	for i, ev := range e.Values {
+		ids[i] = gf.Identifier(ev.Name)
+	}
+
+	// C enums commonly alias more than one name to the same integer value
+	// (deprecated constants, min/max sentinels, ...). Go rejects a switch
+	// with two case expressions that evaluate to the same constant, so only
+	// the first name seen for a given value gets a case below; every alias
+	// still matches at runtime, since they compare equal to v.
+	groups := groupEnumValues(e, ids)
+
+	gf.methods.WriteString("func (v " + name + ") String() string {\n\tswitch v {\n")
+	for _, g := range groups {
+		fmt.Fprintf(&gf.methods, "\tcase %s%s:\n\t\treturn %q\n", name, g.ident, g.label)
+	}
+	fmt.Fprintf(&gf.methods, "\tdefault:\n\t\treturn fmt.Sprintf(\"%s(%%d)\", int32(v))\n\t}\n}\n\n", name)
+
+	gf.methods.WriteString("func (v " + name + ") IsValid() bool {\n\tswitch v {\n\tcase ")
+	for i, g := range groups {
+		if i > 0 {
+			gf.methods.WriteString(", ")
+		}
+		gf.methods.WriteString(name + g.ident)
+	}
+	gf.methods.WriteString(":\n\t\treturn true\n\tdefault:\n\t\treturn false\n\t}\n}\n\n")
+
+	fmt.Fprintf(&gf.methods, "func (v %s) MarshalJSON() ([]byte, error) {\n\treturn json.Marshal(v.String())\n}\n\n", name)
+
+	fmt.Fprintf(&gf.methods, "func (v *%s) UnmarshalJSON(b []byte) error {\n\tvar s string\n\tif err := json.Unmarshal(b, &s); err != nil {\n\t\treturn err\n\t}\n\n\tswitch s {\n", name)
+	for i, ev := range e.Values {
+		fmt.Fprintf(&gf.methods, "\tcase %q:\n\t\t*v = %s%s\n", ev.Name, name, ids[i])
+	}
+	fmt.Fprintf(&gf.methods, "\tdefault:\n\t\treturn fmt.Errorf(\"unknown %s value %%q\", s)\n\t}\n\n\treturn nil\n}\n\n", name)
+}
+
+// enumValueGroup is the first name seen for a given enum value, along with
+// its generated Go identifier. Any further aliases for the same value are
+// dropped: they'd compare equal in a case expression, which Go forbids.
+type enumValueGroup struct {
+	label string // symbolic C name, used for String()/JSON
+	ident string // gf.Identifier(label), used as the case expression
+}
+
+func groupEnumValues(e *Enum, ids []string) []enumValueGroup {
+	seen := make(map[string]bool, len(e.Values))
+	groups := make([]enumValueGroup, 0, len(e.Values))
+	for i, ev := range e.Values {
+		key := fmt.Sprintf("%d", ev.Value)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		groups = append(groups, enumValueGroup{label: ev.Name, ident: ids[i]})
+	}
+	return groups
 }
 
 // writeType outputs the name of a named type or a literal describing the type.
@@ -92,7 +199,9 @@ func (gf *GoFormatter) writeType(typ Type, depth int) error {
 		return nil
 	}
 
-	return gf.writeTypeLit(typ, depth)
+	// Nested occurrences of a type are always written as a literal, so there
+	// is no name available for e.g. bitfield accessor methods.
+	return gf.writeTypeLit(typ, depth, "")
 }
 
 // writeTypeLit outputs a literal describing the type.
@@ -103,7 +212,13 @@ func (gf *GoFormatter) writeType(typ Type, depth int) error {
 //
 //     struct { bar uint32; }
 //     uint32
-func (gf *GoFormatter) writeTypeLit(typ Type, depth int) error {
+//
+// name is the name of the enclosing type declaration, if typ is written
+// directly as the right-hand side of a `type name ...` declaration. It is
+// empty for every nested occurrence, since Go methods can only be attached to
+// named types. Members that require generated methods (for example
+// bitfields) are only supported when name is available.
+func (gf *GoFormatter) writeTypeLit(typ Type, depth int, name string) error {
 	depth++
 	if depth > maxTypeDepth {
 		return errNestedTooDeep
@@ -129,11 +244,25 @@ func (gf *GoFormatter) writeTypeLit(typ Type, depth int) error {
 		err = gf.writeType(v.Type, depth)
 
 	case *Struct:
-		err = gf.writeStructLit(v.Size, v.Members, depth)
+		var fields []structField
+		if fields, err = gf.writeStructLit(name, v.Size, v.Members, depth); err != nil {
+			break
+		}
+		if gf.EmitBinaryCodec && name != "" {
+			err = gf.writeBinaryCodec(name, v.Size, fields)
+		}
 
 	case *Union:
-		// Always choose the first member to repesent the union in Go.
-		err = gf.writeStructLit(v.Size, v.Members[:1], depth)
+		if gf.EmitFullUnions {
+			var accessors *unionAccessors
+			if name != "" {
+				accessors = &unionAccessors{receiverVar: "u", receiver: name, addr: "u"}
+			}
+			err = gf.writeUnionLit(v, depth, accessors)
+		} else {
+			// Always choose the first member to repesent the union in Go.
+			_, err = gf.writeStructLit(name, v.Size, v.Members[:1], depth)
+		}
 
 	case *Datasec:
 		err = gf.writeDatasecLit(v, depth)
@@ -150,48 +279,224 @@ func (gf *GoFormatter) writeTypeLit(typ Type, depth int) error {
 }
 
 func (gf *GoFormatter) writeIntLit(i *Int) {
+	gf.w.WriteString(intTypeName(i))
+}
+
+// intTypeName returns the Go type used to represent a BTF integer.
+func intTypeName(i *Int) string {
 	// NB: Encoding.IsChar is ignored.
 	if i.Encoding.IsBool() && i.Size == 1 {
-		gf.w.WriteString("bool")
-		return
+		return "bool"
 	}
 
 	bits := i.Size * 8
 	if i.Encoding.IsSigned() {
-		fmt.Fprintf(&gf.w, "int%d", bits)
-	} else {
-		fmt.Fprintf(&gf.w, "uint%d", bits)
+		return fmt.Sprintf("int%d", bits)
 	}
+	return fmt.Sprintf("uint%d", bits)
 }
 
-func (gf *GoFormatter) writeStructLit(size uint32, members []Member, depth int) error {
-	gf.w.WriteString("struct { ")
+// writeUintLit writes the name of the unsigned integer type with the given
+// size in bytes, used for bitfield storage units.
+func (gf *GoFormatter) writeUintLit(bytes uint32) {
+	gf.w.WriteString(bitfieldAccessorType(bytes * 8))
+}
 
-	prevOffset := uint32(0)
+// structField describes a single field as actually written into a struct
+// literal by writeStructLit, using its final identifier (after gf.Identifier
+// and any synthesis for bitfield storage units or anonymous members).
+// Anything downstream that needs to refer to a field by name (for example
+// the binary codec) is built from this instead of re-deriving names
+// independently, so it can never drift from what was actually written.
+type structField struct {
+	name   string
+	typ    Type   // nil for synthesized bitfield storage fields
+	offset uint32 // byte offset from the start of the struct
+	size   uint32 // byte size; the storage unit size for bitfields
+}
+
+// flattenAnonymousStructs inlines the fields of anonymous struct members
+// into members, recursively, adjusting OffsetBits so they're relative to
+// the outermost struct. This mirrors C's own treatment of anonymous struct
+// members as if their fields were declared directly on the enclosing type,
+// and as a side effect lets bitfields nested inside an anonymous struct
+// attach their accessors to the (named) outermost type instead of the
+// anonymous struct, which has no name to attach them to.
+func flattenAnonymousStructs(members []Member) ([]Member, error) {
+	var out []Member
 	for i, m := range members {
-		if m.Name == "" {
-			return fmt.Errorf("field %d: anonymous fields are not supported", i)
+		if m.Name != "" {
+			out = append(out, m)
+			continue
+		}
+
+		typ, err := skipQualifiers(m.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %w", i, err)
+		}
+
+		s, ok := typ.(*Struct)
+		if !ok {
+			// Anonymous unions and anonymous bitfields are handled where
+			// they're encountered instead of being flattened here.
+			out = append(out, m)
+			continue
+		}
+
+		nested, err := flattenAnonymousStructs(s.Members)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %w", i, err)
+		}
+
+		for _, nm := range nested {
+			nm.OffsetBits += m.OffsetBits
+			out = append(out, nm)
 		}
+	}
+
+	return out, nil
+}
+
+// unionMember returns typ as a *Union if it is one, skipping
+// qualifiers, and whether that assertion succeeded.
+func unionMember(typ Type) (*Union, bool) {
+	skipped, err := skipQualifiers(typ)
+	if err != nil {
+		return nil, false
+	}
+	u, ok := skipped.(*Union)
+	return u, ok
+}
+
+func (gf *GoFormatter) writeStructLit(name string, size uint32, members []Member, depth int) ([]structField, error) {
+	members, err := flattenAnonymousStructs(members)
+	if err != nil {
+		return nil, err
+	}
+
+	gf.w.WriteString("struct { ")
+
+	var fields []structField
+	prevOffset := uint32(0)
+	bitfieldUnit := 0
+	anonFields := 0
+	for i := 0; i < len(members); i++ {
+		m := members[i]
+
 		if m.BitfieldSize > 0 {
-			return fmt.Errorf("field %d: bitfields are not supported", i)
+			if m.Name == "" {
+				return nil, fmt.Errorf("field %d: anonymous bitfields are not supported", i)
+			}
+			if name == "" {
+				return nil, fmt.Errorf("field %d: bitfields are only supported on named types", i)
+			}
+
+			group, n, err := groupBitfields(members[i:])
+			if err != nil {
+				return nil, fmt.Errorf("field %d: %w", i, err)
+			}
+
+			offset := group.offsetBits / 8
+			if offset < prevOffset {
+				// The naive "align to the first bitfield's own type size"
+				// rule guessed a storage unit that starts before the
+				// previous field ends, most likely because the struct is
+				// packed. Emitting padding here would underflow, so bail
+				// out instead of producing a bogus multi-gigabyte array.
+				return nil, fmt.Errorf("field %d: bitfield storage unit at offset %d overlaps preceding field ending at %d", i, offset, prevOffset)
+			}
+
+			// The same guess can also overrun the far end: the unit is
+			// sized to the first bitfield's own C type, which can be wider
+			// than the gap the struct actually packs it into. Compare
+			// against whatever comes next (the following member, or the
+			// end of the struct if this group is the last field) and bail
+			// out rather than let the following writePadding underflow.
+			groupEnd := offset + group.sizeBits/8
+			nextOffsetBits := size * 8
+			if i+n < len(members) {
+				nextOffsetBits = members[i+n].OffsetBits
+			}
+			if groupEnd*8 > nextOffsetBits {
+				return nil, fmt.Errorf("field %d: bitfield storage unit ending at byte %d overruns next field at offset %d", i, groupEnd, nextOffsetBits/8)
+			}
+
+			gf.writePadding(offset - prevOffset)
+			prevOffset = groupEnd
+
+			storageName := gf.Identifier(fmt.Sprintf("_bitfield%d", bitfieldUnit))
+			bitfieldUnit++
+
+			fmt.Fprintf(&gf.w, "%s ", storageName)
+			gf.writeUintLit(group.sizeBits / 8)
+			gf.w.WriteString("; ")
+
+			storageType := bitfieldAccessorType(group.sizeBits)
+			for _, bm := range group.members {
+				signed, err := bitfieldSigned(bm.Type)
+				if err != nil {
+					return nil, fmt.Errorf("field %d: %w", i, err)
+				}
+				if signed {
+					// Accessors only know how to mask and shift bits into
+					// an unsigned storage type; sign-extending a signed
+					// bitfield correctly needs more than that, so reject it
+					// instead of silently returning the wrong value.
+					return nil, fmt.Errorf("field %d: signed bitfields are not supported", i)
+				}
+
+				gf.writeBitfieldAccessors(name, storageName, storageType, bm, bm.OffsetBits-group.offsetBits)
+			}
+
+			fields = append(fields, structField{
+				name:   storageName,
+				offset: group.offsetBits / 8,
+				size:   group.sizeBits / 8,
+			})
+
+			i += n - 1
+			continue
 		}
+
 		if m.OffsetBits%8 != 0 {
-			return fmt.Errorf("field %d: unsupported offset %d", i, m.OffsetBits)
+			return nil, fmt.Errorf("field %d: unsupported offset %d", i, m.OffsetBits)
 		}
 
 		size, err := Sizeof(m.Type)
 		if err != nil {
-			return fmt.Errorf("field %d: %w", i, err)
+			return nil, fmt.Errorf("field %d: %w", i, err)
+		}
+
+		// Anonymous struct members were already flattened away above.
+		// What's left here (anonymous unions, in practice) still needs a
+		// stable field name to be addressable at all; synthesize one
+		// rather than rejecting the member outright.
+		fieldName := m.Name
+		if fieldName == "" {
+			fieldName = fmt.Sprintf("_anon%d", anonFields)
+			anonFields++
 		}
+		fieldName = gf.Identifier(fieldName)
 
 		offset := m.OffsetBits / 8
 		gf.writePadding(offset - prevOffset)
 		prevOffset = offset + uint32(size)
 
-		fmt.Fprintf(&gf.w, "%s ", gf.Identifier(m.Name))
-
-		if err := gf.writeType(m.Type, depth); err != nil {
-			return fmt.Errorf("field %d: %w", i, err)
+		fields = append(fields, structField{name: fieldName, typ: m.Type, offset: offset, size: uint32(size)})
+
+		fmt.Fprintf(&gf.w, "%s ", fieldName)
+
+		if union, ok := unionMember(m.Type); ok && gf.EmitFullUnions && name != "" {
+			// A named or anonymous union field: attach its accessor
+			// methods to the enclosing named type, addressed through
+			// this field, instead of falling back to the "no accessors"
+			// path writeUnionLit takes when it has no name of its own.
+			accessors := &unionAccessors{receiverVar: "v", receiver: name, addr: "&v." + fieldName}
+			if err := gf.writeUnionLit(union, depth, accessors); err != nil {
+				return nil, fmt.Errorf("field %d: %w", i, err)
+			}
+		} else if err := gf.writeType(m.Type, depth); err != nil {
+			return nil, fmt.Errorf("field %d: %w", i, err)
 		}
 
 		gf.w.WriteString("; ")
@@ -199,9 +504,316 @@ func (gf *GoFormatter) writeStructLit(size uint32, members []Member, depth int)
 
 	gf.writePadding(size - prevOffset)
 	gf.w.WriteString("}")
+	return fields, nil
+}
+
+// unionAccessors describes how a union's per-member accessor methods should
+// be declared: on which named Go type, under which receiver variable name,
+// and through which expression (in terms of that receiver) the union's
+// bytes are reached. A top-level named union addresses itself directly; a
+// union embedded as a struct field, named or anonymous, addresses the field
+// instead, so its accessors end up on the enclosing named type.
+type unionAccessors struct {
+	receiverVar string // e.g. "u" or "v"
+	receiver    string // named Go type the methods are declared on
+	addr        string // expression yielding a pointer to the union's bytes
+}
+
+// writeUnionLit emits a union as a byte array sized to v.Size and, if
+// accessors is non-nil, an AsFoo accessor method per member that
+// reinterprets those bytes as the member's type. Unlike the "first member"
+// representation, this preserves every variant and any bytes beyond the
+// first member's size.
+func (gf *GoFormatter) writeUnionLit(v *Union, depth int, accessors *unionAccessors) error {
+	fmt.Fprintf(&gf.w, "[%d]byte", v.Size)
+
+	if accessors == nil {
+		// No named type exists to attach accessor methods to.
+		return nil
+	}
+
+	for i, m := range v.Members {
+		if m.Name == "" {
+			return fmt.Errorf("member %d: anonymous union members are not supported", i)
+		}
+
+		typeName, err := gf.unionAccessorType(m.Type, depth)
+		if err != nil {
+			return fmt.Errorf("member %d: %w", i, err)
+		}
+
+		fmt.Fprintf(&gf.methods, "func (%s *%s) As%s() *%s {\n\treturn (*%s)(unsafe.Pointer(%s))\n}\n\n",
+			accessors.receiverVar, accessors.receiver, gf.Identifier(m.Name), typeName, typeName, accessors.addr)
+	}
+
+	return nil
+}
+
+// typeName returns the Go spelling of typ as used outside of the single
+// writeType call that renders it inline, for generated code (accessor
+// signatures, casts) that needs to refer to a member's type by name. Only
+// named types (present in gf.names) and basic integers/enums have such a
+// stable spelling.
+func (gf *GoFormatter) typeName(typ Type) (string, error) {
+	typ, err := skipQualifiers(typ)
+	if err != nil {
+		return "", err
+	}
+
+	if name := gf.names[typ]; name != "" {
+		return name, nil
+	}
+
+	switch v := typ.(type) {
+	case *Int:
+		return intTypeName(v), nil
+	case *Enum:
+		return "int32", nil
+	case *Typedef:
+		return gf.typeName(v.Type)
+	default:
+		return "", fmt.Errorf("type %s: needs a name or must be an integer to generate code referring to it", typ)
+	}
+}
+
+// renderType returns the Go spelling of typ exactly as writeType would emit
+// it inline, without disturbing anything already written to gf.w. Used to
+// get a usable type spelling for values that have no stable name of their
+// own, such as an array or an anonymous struct/union.
+func (gf *GoFormatter) renderType(typ Type, depth int) (string, error) {
+	saved := gf.w
+	gf.w = strings.Builder{}
+	err := gf.writeType(typ, depth)
+	rendered := gf.w.String()
+	gf.w = saved
+	if err != nil {
+		return "", err
+	}
+	return rendered, nil
+}
+
+// unionAccessorType returns the Go type to use for a union member accessor's
+// return value. It prefers typeName's stable spelling; arrays and anonymous
+// struct/union overlays have none, but are still valid targets for a
+// pointer accessor, so those fall back to rendering the type literal as it
+// would be written inline (e.g. "[4]uint8" or "struct { foo uint32; }").
+func (gf *GoFormatter) unionAccessorType(typ Type, depth int) (string, error) {
+	if name, err := gf.typeName(typ); err == nil {
+		return name, nil
+	}
+
+	skipped, err := skipQualifiers(typ)
+	if err != nil {
+		return "", err
+	}
+
+	switch skipped.(type) {
+	case *Array, *Struct, *Union:
+		return gf.renderType(typ, depth)
+	default:
+		return "", fmt.Errorf("type %s: needs a name, or must be an integer, array or composite type, to generate an accessor", typ)
+	}
+}
+
+// bitfieldGroup is a run of adjacent bitfield members that share a single
+// storage field in the generated struct.
+type bitfieldGroup struct {
+	offsetBits uint32 // offset of the storage unit from the start of the struct
+	sizeBits   uint32 // size of the storage unit in bits, always a multiple of 8
+	members    []Member
+}
+
+// groupBitfields splits off the leading run of members that pack into the
+// same storage unit as members[0], which must be a bitfield.
+func groupBitfields(members []Member) (bitfieldGroup, int, error) {
+	first := members[0]
+
+	unitBytes, err := Sizeof(first.Type)
+	if err != nil {
+		return bitfieldGroup{}, 0, fmt.Errorf("determine storage unit: %w", err)
+	}
+
+	unitBits := uint32(unitBytes) * 8
+	unitStart := (first.OffsetBits / unitBits) * unitBits
+
+	group := bitfieldGroup{offsetBits: unitStart, sizeBits: unitBits}
+	n := 0
+	for _, m := range members {
+		if m.BitfieldSize == 0 || m.OffsetBits >= unitStart+unitBits {
+			break
+		}
+
+		group.members = append(group.members, m)
+		n++
+	}
+
+	return group, n, nil
+}
+
+// writeBitfieldAccessors emits a getter and setter method that mask and
+// shift a bitfield in and out of storageName, which must be an unsigned
+// integer field on name.
+func (gf *GoFormatter) writeBitfieldAccessors(name, storageName, storageType string, m Member, shift uint32) {
+	fieldName := gf.Identifier(m.Name)
+	mask := uint64(1)<<m.BitfieldSize - 1
+	accessorType := bitfieldAccessorType(m.BitfieldSize)
+
+	fmt.Fprintf(&gf.methods, "func (v *%s) %s() %s {\n\treturn %s((v.%s >> %d) & 0x%x)\n}\n\n",
+		name, fieldName, accessorType, accessorType, storageName, shift, mask)
+
+	fmt.Fprintf(&gf.methods, "func (v *%s) Set%s(value %s) {\n\tv.%s = v.%s&^(%s(0x%x)<<%d) | (%s(value)&%s(0x%x))<<%d\n}\n\n",
+		name, fieldName, accessorType,
+		storageName, storageName, storageType, mask, shift,
+		storageType, storageType, mask, shift)
+}
+
+// bitfieldSigned reports whether typ is a signed integer. Accessors mask and
+// shift bits into an unsigned storage field, which would silently drop the
+// sign of a signed bitfield, so callers use this to reject them up front.
+func bitfieldSigned(typ Type) (bool, error) {
+	skipped, err := skipQualifiers(typ)
+	if err != nil {
+		return false, err
+	}
+
+	i, ok := skipped.(*Int)
+	if !ok {
+		return false, nil
+	}
+
+	return i.Encoding.IsSigned(), nil
+}
+
+// bitfieldAccessorType returns the narrowest unsigned integer type that can
+// hold a bitfield of the given width.
+func bitfieldAccessorType(bits uint32) string {
+	switch {
+	case bits <= 8:
+		return "uint8"
+	case bits <= 16:
+		return "uint16"
+	case bits <= 32:
+		return "uint32"
+	default:
+		return "uint64"
+	}
+}
+
+// codecField describes a single fixed-width, byte-aligned struct field for
+// the purposes of generating a binary codec.
+type codecField struct {
+	name     string
+	typeName string
+	offset   uint32
+	size     uint32
+}
+
+// resolveCodecFields turns the fields writeStructLit actually wrote into
+// codecFields, reusing their names verbatim so the codec can never refer to
+// a field under a different spelling than gf.Identifier produced. It fails
+// if a field isn't a plain fixed-width integer or enum (or a bitfield
+// storage unit, which is already one), since those are the only kinds
+// encoding/binary can read and write directly.
+func (gf *GoFormatter) resolveCodecFields(fields []structField) ([]codecField, error) {
+	out := make([]codecField, 0, len(fields))
+	for i, f := range fields {
+		if f.typ == nil {
+			// Synthesized bitfield storage unit: already an unsigned
+			// integer of the right width, named exactly as written.
+			out = append(out, codecField{
+				name:     f.name,
+				typeName: bitfieldAccessorType(f.size * 8),
+				offset:   f.offset,
+				size:     f.size,
+			})
+			continue
+		}
+
+		typ, err := skipQualifiers(f.typ)
+		if err != nil {
+			return nil, fmt.Errorf("field %d (%s): %w", i, f.name, err)
+		}
+
+		switch v := typ.(type) {
+		case *Int:
+			if v.Encoding.IsBool() {
+				return nil, fmt.Errorf("field %d (%s): bool fields are not supported by the binary codec", i, f.name)
+			}
+		case *Enum:
+			// Encoded the same way as an *Int above.
+		default:
+			return nil, fmt.Errorf("field %d (%s): %s: %w", i, f.name, typ, ErrNotSupported)
+		}
+
+		typeName, err := gf.typeName(f.typ)
+		if err != nil {
+			return nil, fmt.Errorf("field %d (%s): %w", i, f.name, err)
+		}
+
+		out = append(out, codecField{
+			name:     f.name,
+			typeName: typeName,
+			offset:   f.offset,
+			size:     f.size,
+		})
+	}
+
+	return out, nil
+}
+
+// writeBinaryCodec emits MarshalBinary and UnmarshalBinary methods on name,
+// encoding every field of the struct via encoding/binary according to
+// gf.ByteOrder. Padding bytes are skipped rather than treated as data.
+func (gf *GoFormatter) writeBinaryCodec(name string, size uint32, structFields []structField) error {
+	fields, err := gf.resolveCodecFields(structFields)
+	if err != nil {
+		return fmt.Errorf("binary codec: %w", err)
+	}
+
+	order := "binary.LittleEndian"
+	if gf.ByteOrder == binary.BigEndian {
+		order = "binary.BigEndian"
+	}
+
+	fmt.Fprintf(&gf.methods, "func (v *%s) MarshalBinary() ([]byte, error) {\n\tbuf := make([]byte, %d)\n", name, size)
+	for _, f := range fields {
+		gf.writeFieldPut(order, f)
+	}
+	gf.methods.WriteString("\treturn buf, nil\n}\n\n")
+
+	fmt.Fprintf(&gf.methods,
+		"func (v *%s) UnmarshalBinary(data []byte) error {\n\tif len(data) != %d {\n\t\treturn fmt.Errorf(\"%s: expected %d bytes, got %%d\", len(data))\n\t}\n\n",
+		name, size, name, size)
+	for _, f := range fields {
+		gf.writeFieldGet(order, f)
+	}
+	gf.methods.WriteString("\treturn nil\n}\n\n")
+
 	return nil
 }
 
+// writeFieldPut emits the line of MarshalBinary that copies a single field
+// into buf.
+func (gf *GoFormatter) writeFieldPut(order string, f codecField) {
+	if f.size == 1 {
+		fmt.Fprintf(&gf.methods, "\tbuf[%d] = byte(v.%s)\n", f.offset, f.name)
+		return
+	}
+
+	fmt.Fprintf(&gf.methods, "\t%s.PutUint%d(buf[%d:], uint%d(v.%s))\n", order, f.size*8, f.offset, f.size*8, f.name)
+}
+
+// writeFieldGet emits the line of UnmarshalBinary that reads a single field
+// back out of data.
+func (gf *GoFormatter) writeFieldGet(order string, f codecField) {
+	if f.size == 1 {
+		fmt.Fprintf(&gf.methods, "\tv.%s = %s(data[%d])\n", f.name, f.typeName, f.offset)
+		return
+	}
+
+	fmt.Fprintf(&gf.methods, "\tv.%s = %s(%s.Uint%d(data[%d:]))\n", f.name, f.typeName, order, f.size*8, f.offset)
+}
+
 func (gf *GoFormatter) writeDatasecLit(ds *Datasec, depth int) error {
 	gf.w.WriteString("struct { ")
 
@@ -0,0 +1,130 @@
+package btf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func mustDeclare(t *testing.T, gf *GoFormatter, name string, typ Type) string {
+	t.Helper()
+	out, err := gf.TypeDeclaration(name, typ)
+	if err != nil {
+		t.Fatalf("TypeDeclaration(%q): %v", name, err)
+	}
+	return out
+}
+
+func TestGoFormatterBitfieldGroupOverrunsNextField(t *testing.T) {
+	// Packed tighter than the bitfield's own type would naively suggest: a
+	// single bit backed by a u64 at byte 8, squeezed into the one byte ahead
+	// of the next field, so a u64-sized storage unit there can't fit without
+	// running past where "b" starts at byte 9.
+	u32 := &Int{Size: 4}
+	u64 := &Int{Size: 8}
+
+	s := &Struct{
+		Size: 12,
+		Members: []Member{
+			{Name: "a", Type: u32, OffsetBits: 0},
+			{Name: "flag", Type: u64, OffsetBits: 64, BitfieldSize: 1},
+			{Name: "b", Type: u32, OffsetBits: 72},
+		},
+	}
+
+	gf := NewGoFormatter(nil)
+	if _, err := gf.TypeDeclaration("Packed", s); err == nil {
+		t.Fatal("expected an error for a bitfield storage unit overrunning the next field, got nil")
+	}
+}
+
+func TestGoFormatterSignedBitfieldRejected(t *testing.T) {
+	s := &Struct{
+		Size: 4,
+		Members: []Member{
+			{Name: "x", Type: &Int{Size: 4, Encoding: Signed}, OffsetBits: 0, BitfieldSize: 4},
+		},
+	}
+
+	gf := NewGoFormatter(nil)
+	if _, err := gf.TypeDeclaration("Signed", s); err == nil {
+		t.Fatal("expected an error for a signed bitfield, got nil")
+	}
+}
+
+func TestGoFormatterEnumDuplicateValues(t *testing.T) {
+	e := &Enum{
+		Values: []EnumValue{
+			{Name: "A", Value: 0},
+			{Name: "ALIAS_A", Value: 0},
+			{Name: "B", Value: 1},
+		},
+	}
+
+	gf := NewGoFormatter(nil)
+	gf.EmitEnumMethods = true
+
+	out := mustDeclare(t, gf, "E", e)
+
+	if strings.Count(out, "case EA:") != 1 {
+		t.Fatalf("expected exactly one case for the value shared by A and ALIAS_A, got:\n%s", out)
+	}
+	if !strings.Contains(out, "EALIAS_A E = 0;") {
+		t.Fatalf("expected ALIAS_A to still get its own constant declared, got:\n%s", out)
+	}
+}
+
+func TestGoFormatterUnionArrayAndAnonymousStructMembers(t *testing.T) {
+	anon := &Struct{
+		Size: 8,
+		Members: []Member{
+			{Name: "x", Type: &Int{Size: 4}, OffsetBits: 0},
+			{Name: "y", Type: &Int{Size: 4}, OffsetBits: 32},
+		},
+	}
+
+	u := &Union{
+		Size: 8,
+		Members: []Member{
+			{Name: "point", Type: anon, OffsetBits: 0},
+			{Name: "buf", Type: &Array{Nelems: 8, Type: &Int{Size: 1}}, OffsetBits: 0},
+		},
+	}
+
+	gf := NewGoFormatter(nil)
+	gf.EmitFullUnions = true
+
+	out := mustDeclare(t, gf, "U", u)
+
+	if !strings.Contains(out, "Aspoint() *struct { x uint32; y uint32; }") {
+		t.Fatalf("expected an accessor for the anonymous struct member, got:\n%s", out)
+	}
+	if !strings.Contains(out, "Asbuf() *[8]uint8") {
+		t.Fatalf("expected an accessor for the array member, got:\n%s", out)
+	}
+}
+
+func TestGoFormatterBinaryCodecRejectsUnionField(t *testing.T) {
+	u := &Union{
+		Size: 4,
+		Members: []Member{
+			{Name: "n", Type: &Int{Size: 4}, OffsetBits: 0},
+		},
+	}
+
+	s := &Struct{
+		Size: 4,
+		Members: []Member{
+			{Name: "variant", Type: u, OffsetBits: 0},
+		},
+	}
+
+	gf := NewGoFormatter(nil)
+	gf.EmitFullUnions = true
+	gf.EmitBinaryCodec = true
+
+	_, err := gf.TypeDeclaration("S", s)
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected %v for a union field, got %v", ErrNotSupported, err)
+	}
+}